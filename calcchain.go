@@ -62,6 +62,29 @@ func (f *File) deleteCalcChain(index int, axis string) {
 	}
 }
 
+// addCalcChain appends a cell reference on sheetID to the calculation
+// chain, so that Excel recalculates it when the workbook is next opened.
+// xl/calcChain.xml is registered in [Content_Types].xml the first time the
+// workbook gains one.
+func (f *File) addCalcChain(sheetID int, axis string) {
+	calc := f.calcChainReader()
+	if calc == nil {
+		calc = new(xlsxCalcChain)
+		f.CalcChain = calc
+	}
+	calc.C = append(calc.C, xlsxCalcChainC{I: sheetID, R: axis})
+	content := f.contentTypesReader()
+	for _, o := range content.Overrides {
+		if o.PartName == "/xl/calcChain.xml" {
+			return
+		}
+	}
+	content.Overrides = append(content.Overrides, xlsxOverride{
+		PartName:    "/xl/calcChain.xml",
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.calcChain+xml",
+	})
+}
+
 type xlsxCalcChainCollection []xlsxCalcChainC
 
 // Filter provides a function to filter calculation chain.
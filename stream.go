@@ -11,23 +11,95 @@ package excelize
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"regexp"
+	"strconv"
 	"time"
 )
 
 // StreamWriter defined the type of stream writer.
 type StreamWriter struct {
-	tmpFile   *os.File
-	File      *File
-	Sheet     string
-	SheetID   int
-	SheetData bytes.Buffer
-	encoder   *xml.Encoder
+	tmpFile         *os.File
+	File            *File
+	Sheet           string
+	SheetID         int
+	SheetData       bytes.Buffer
+	encoder         *xml.Encoder
+	options         StreamWriterOptions
+	sst             *streamWriterSST
+	cols            bytes.Buffer
+	mergeCells      bytes.Buffer
+	mergeCellsCount int
+	calcChainCells  []string
+	fullCalcOnLoad  bool
+}
+
+// Formula represents a formula cell value accepted by StreamWriter.SetRow.
+// T selects the formula type: empty for a plain formula, "shared" for a
+// shared formula (SI identifies the group, Ref its range), and "array" for
+// an array formula applied over Ref. If Result is nil, the workbook is
+// marked to recalculate on load instead of writing a precomputed value.
+type Formula struct {
+	F      string
+	T      string
+	Ref    string
+	SI     int
+	Result interface{}
+}
+
+// RowOpts defines options for a single row written by StreamWriter.SetRow.
+type RowOpts struct {
+	// Height, if greater than zero, sets a custom row height in points
+	// instead of the default row height.
+	Height float64
+}
+
+// StreamWriterOptions defines options for NewStreamWriterWithOptions.
+type StreamWriterOptions struct {
+	// UseSharedStrings, if true, interns string cell values into the
+	// workbook's shared strings table and emits cells as t="s" with an
+	// integer index instead of writing each string inline as t="str".
+	// This is worthwhile when a sheet has many repeated string values,
+	// such as status codes or country names.
+	UseSharedStrings bool
+	// SharedStringsSpillThreshold is the number of distinct strings the
+	// shared strings intern table keeps in memory before spilling to a
+	// temporary file on local storage, so a sheet with millions of unique
+	// strings doesn't OOM. Defaults to
+	// defaultStreamWriterSSTSpillThreshold when zero or negative. Only
+	// meaningful when UseSharedStrings is true.
+	SharedStringsSpillThreshold int
+	// SpillThresholdBytes is the size to which SheetData is allowed to
+	// grow before SetRow spills it to a temporary file on local storage.
+	// Defaults to defaultStreamWriterSpillThreshold when zero or negative.
+	SpillThresholdBytes int64
+	// TempDir is the directory in which the temporary spill file is
+	// created. Defaults to os.TempDir() when empty.
+	TempDir string
+	// DisableSpill, if true, keeps all buffered row data in memory and
+	// never spills to a temporary file, regardless of SpillThresholdBytes.
+	DisableSpill bool
+}
+
+// defaultStreamWriterSpillThreshold is the SheetData size, in bytes, at
+// which StreamWriter.SetRow spills buffered rows to a temporary file when
+// StreamWriterOptions.SpillThresholdBytes is not set.
+const defaultStreamWriterSpillThreshold = 1 << 24
+
+// spillThreshold returns the effective spill threshold for sw, applying
+// defaultStreamWriterSpillThreshold when none was configured.
+func (sw *StreamWriter) spillThreshold() int {
+	if sw.options.SpillThresholdBytes > 0 {
+		return int(sw.options.SpillThresholdBytes)
+	}
+	return defaultStreamWriterSpillThreshold
 }
 
 // NewStreamWriter return stream writer struct by given worksheet name for
@@ -60,6 +132,18 @@ type StreamWriter struct {
 //    }
 //
 func (f *File) NewStreamWriter(sheet string) (*StreamWriter, error) {
+	return f.NewStreamWriterWithOptions(sheet, StreamWriterOptions{})
+}
+
+// NewStreamWriterWithOptions return stream writer struct by given worksheet
+// name and options, for example to enable the shared strings table for
+// string cell values:
+//
+//    streamWriter, err := file.NewStreamWriterWithOptions("Sheet1", excelize.StreamWriterOptions{
+//        UseSharedStrings: true,
+//    })
+//
+func (f *File) NewStreamWriterWithOptions(sheet string, opts StreamWriterOptions) (*StreamWriter, error) {
 	sheetID := f.GetSheetIndex(sheet)
 	if sheetID == 0 {
 		return nil, fmt.Errorf("sheet %s is not exist", sheet)
@@ -68,6 +152,10 @@ func (f *File) NewStreamWriter(sheet string) (*StreamWriter, error) {
 		File:    f,
 		Sheet:   sheet,
 		SheetID: sheetID,
+		options: opts,
+	}
+	if opts.UseSharedStrings {
+		rsw.sst = newStreamWriterSST(opts.SharedStringsSpillThreshold, opts.TempDir)
 	}
 	rsw.encoder = xml.NewEncoder(&rsw.SheetData)
 	rsw.SheetData.WriteString("<sheetData>")
@@ -77,9 +165,10 @@ func (f *File) NewStreamWriter(sheet string) (*StreamWriter, error) {
 // SetRow writes an array to stream rows by giving a worksheet name, starting
 // coordinate and a pointer to an array of values. If styles is non-nil, then
 // the styles must be the same size as the values and will be applied to each
-// corresponding cell. Note that you must call the 'Flush' method to end the
-// streaming writing process.
-func (sw *StreamWriter) SetRow(axis string, values []interface{}, styles []int) error {
+// corresponding cell. An optional RowOpts sets a custom row height. Note
+// that you must call the 'Flush' method to end the streaming writing
+// process.
+func (sw *StreamWriter) SetRow(axis string, values []interface{}, styles []int, opts ...RowOpts) error {
 	col, row, err := CellNameToCoordinates(axis)
 	if err != nil {
 		return err
@@ -90,74 +179,49 @@ func (sw *StreamWriter) SetRow(axis string, values []interface{}, styles []int)
 	if len(styles) != len(values) {
 		return errors.New("incorrect number of styles for this row")
 	}
-	sw.SheetData.WriteString(fmt.Sprintf(`<row r="%d">`, row))
+	var intern func(string) (string, error)
+	if sw.options.UseSharedStrings {
+		intern = sw.sst.intern
+	}
+	rowAttrs := fmt.Sprintf(`r="%d"`, row)
+	if len(opts) > 0 && opts[0].Height > 0 {
+		rowAttrs += fmt.Sprintf(` ht="%v" customHeight="1"`, opts[0].Height)
+	}
+	sw.SheetData.WriteString(fmt.Sprintf(`<row %s>`, rowAttrs))
 	for i, val := range values {
 		axis, err := CoordinatesToCellName(col+i, row)
 		if err != nil {
 			return err
 		}
-		c := xlsxC{R: axis, S: styles[i]}
-		switch val := val.(type) {
-		case int:
-			c.T, c.V = setCellInt(val)
-		case int8:
-			c.T, c.V = setCellInt(int(val))
-		case int16:
-			c.T, c.V = setCellInt(int(val))
-		case int32:
-			c.T, c.V = setCellInt(int(val))
-		case int64:
-			c.T, c.V = setCellInt(int(val))
-		case uint:
-			c.T, c.V = setCellInt(int(val))
-		case uint8:
-			c.T, c.V = setCellInt(int(val))
-		case uint16:
-			c.T, c.V = setCellInt(int(val))
-		case uint32:
-			c.T, c.V = setCellInt(int(val))
-		case uint64:
-			c.T, c.V = setCellInt(int(val))
-		case float32:
-			c.T, c.V = setCellFloat(float64(val), -1, 32)
-		case float64:
-			c.T, c.V = setCellFloat(val, -1, 64)
-		case string:
-			c.T, c.V, c.XMLSpace = setCellStr(val)
-		case []byte:
-			c.T, c.V, c.XMLSpace = setCellStr(string(val))
-		case time.Duration:
-			c.T, c.V = setCellDuration(val)
-		case time.Time:
-			c.T, c.V, _, err = setCellTime(val)
-		case bool:
-			c.T, c.V = setCellBool(val)
-		case nil:
-			c.T, c.V, c.XMLSpace = setCellStr("")
-		default:
-			c.T, c.V, c.XMLSpace = setCellStr(fmt.Sprint(val))
-		}
-		sw.encoder.Encode(c)
+		if formula, ok := val.(Formula); ok {
+			if err := sw.encodeFormulaCell(axis, styles[i], formula, intern); err != nil {
+				return err
+			}
+			continue
+		}
+		c, err := makeStreamCell(axis, styles[i], val, intern)
+		if err != nil {
+			return err
+		}
+		if err := sw.encoder.Encode(c); err != nil {
+			return err
+		}
 	}
 	sw.SheetData.WriteString(`</row>`)
 	// Try to use local storage
-	chunk := 1 << 24
-	if sw.SheetData.Len() >= chunk {
+	if !sw.options.DisableSpill && sw.SheetData.Len() >= sw.spillThreshold() {
 		if sw.tmpFile == nil {
-			err := sw.createTmp()
-			if err != nil {
-				// can not use local storage
-				return nil
+			if err := sw.createTmp(); err != nil {
+				return err
 			}
 		}
 		// use local storage
-		_, err := sw.tmpFile.Write(sw.SheetData.Bytes())
-		if err != nil {
-			return nil
+		if _, err := sw.tmpFile.Write(sw.SheetData.Bytes()); err != nil {
+			return err
 		}
 		sw.SheetData.Reset()
 	}
-	return err
+	return nil
 }
 
 // Flush ending the streaming writing process.
@@ -171,6 +235,19 @@ func (sw *StreamWriter) Flush() error {
 	sheetXML := fmt.Sprintf("xl/worksheets/sheet%d.xml", sw.SheetID)
 	delete(sw.File.Sheet, sheetXML)
 	delete(sw.File.checked, sheetXML)
+	for _, axis := range sw.calcChainCells {
+		sw.File.addCalcChain(sw.SheetID, axis)
+	}
+	if sw.fullCalcOnLoad {
+		// fullCalcOnLoad belongs on the workbook's own <calcPr>, not on the
+		// worksheet's <sheetPr>, which has no calcPr child in the OOXML
+		// schema.
+		wb := sw.File.workBookReader()
+		if wb.CalcPr == nil {
+			wb.CalcPr = &xlsxCalcPr{}
+		}
+		wb.CalcPr.FullCalcOnLoad = true
+	}
 	var sheetDataByte []byte
 	if sw.tmpFile != nil {
 		// close the local storage file
@@ -196,23 +273,95 @@ func (sw *StreamWriter) Flush() error {
 		if err != nil {
 			return err
 		}
+		sw.tmpFile = nil
 	}
 
 	sheetDataByte = append(sheetDataByte, sw.SheetData.Bytes()...)
+	sheetDataByte, err = sw.mergeSharedStrings(sheetDataByte)
+	if err != nil {
+		return err
+	}
 	replaceMap := map[string][]byte{
 		"XMLName":   []byte{},
 		"SheetData": sheetDataByte,
 	}
+	// Cols and MergeCells are schema siblings of SheetData: cols must come
+	// before sheetData and mergeCells must come after it. Leaving them out
+	// of replaceMap when unused preserves whatever the worksheet already
+	// had for these fields.
+	if sw.cols.Len() > 0 {
+		replaceMap["Cols"] = []byte(fmt.Sprintf(`<cols>%s</cols>`, sw.cols.String()))
+	}
+	if sw.mergeCellsCount > 0 {
+		replaceMap["MergeCells"] = []byte(fmt.Sprintf(`<mergeCells count="%d">%s</mergeCells>`, sw.mergeCellsCount, sw.mergeCells.String()))
+	}
 	sw.SheetData.Reset()
 	sw.File.XLSX[fmt.Sprintf("xl/worksheets/sheet%d.xml", sw.SheetID)] =
 		StreamMarshalSheet(ws, replaceMap)
 	return err
 }
 
-// createTmp creates a temporary file in the operating system default
-// temporary directory.
+// MergeCell provides a function to merge cells by given range reference for
+// the StreamWriter. Don't create a merged cell that overlaps with another
+// existing merged cell, Excel doesn't support that. Merges are buffered and
+// written out by Flush.
+func (sw *StreamWriter) MergeCell(hCell, vCell string) error {
+	if _, _, err := CellNameToCoordinates(hCell); err != nil {
+		return err
+	}
+	if _, _, err := CellNameToCoordinates(vCell); err != nil {
+		return err
+	}
+	sw.mergeCellsCount++
+	sw.mergeCells.WriteString(fmt.Sprintf(`<mergeCell ref="%s:%s"/>`, hCell, vCell))
+	return nil
+}
+
+// SetColWidth provides a function to set the width of a single column or
+// multiple columns for the StreamWriter, identified by the given min and
+// max column index, ranging from 1 to 16384. Column widths are buffered
+// and written out by Flush.
+func (sw *StreamWriter) SetColWidth(min, max int, width float64) error {
+	if min > TotalColumns || max > TotalColumns {
+		return ErrColumnNumber
+	}
+	if min < 1 || max < 1 {
+		return ErrColumnNumber
+	}
+	if min > max {
+		min, max = max, min
+	}
+	sw.cols.WriteString(fmt.Sprintf(`<col min="%d" max="%d" width="%f" customWidth="1"/>`, min, max, width))
+	return nil
+}
+
+// createTmp creates a temporary file in StreamWriterOptions.TempDir, or the
+// operating system default temporary directory if it wasn't set.
 func (sw *StreamWriter) createTmp() (err error) {
-	sw.tmpFile, err = ioutil.TempFile(os.TempDir(), "excelize-")
+	dir := sw.options.TempDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	sw.tmpFile, err = ioutil.TempFile(dir, "excelize-")
+	return err
+}
+
+// Close discards the temporary file, if any, that SetRow spilled buffered
+// row data to. Flush already closes and removes this temporary file once
+// streaming finishes successfully, so Close is only needed to avoid leaking
+// it when a StreamWriter is abandoned before Flush is called, for example
+// because SetRow returned an error. It is safe to call Close more than
+// once, and after a successful Flush.
+func (sw *StreamWriter) Close() error {
+	if sw.tmpFile == nil {
+		return nil
+	}
+	name := sw.tmpFile.Name()
+	err := sw.tmpFile.Close()
+	sw.tmpFile = nil
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
 	return err
 }
 
@@ -237,6 +386,394 @@ func StreamMarshalSheet(ws *xlsxWorksheet, replaceMap map[string][]byte) []byte
 	return marshalResult
 }
 
+// encodeFormulaCell encodes a formula cell written via SetRow. If
+// formula.Result is nil, no precomputed value is written and the workbook
+// is marked to recalculate all formulas when it is next opened, since
+// StreamWriter has no formula engine of its own. The cell is also recorded
+// so Flush can append it to xl/calcChain.xml. intern is the same shared
+// strings interning function passed to makeStreamCell for ordinary cells,
+// so a string-valued formula.Result is deduped through the sheet's shared
+// strings table instead of always being written inline.
+func (sw *StreamWriter) encodeFormulaCell(axis string, style int, formula Formula, intern func(string) (string, error)) error {
+	c := xlsxC{R: axis, S: style}
+	if formula.Result != nil {
+		result, err := makeStreamCell(axis, style, formula.Result, intern)
+		if err != nil {
+			return err
+		}
+		c.T, c.V = result.T, result.V
+	} else {
+		sw.fullCalcOnLoad = true
+	}
+	f := &xlsxF{Content: formula.F, Ref: formula.Ref}
+	switch formula.T {
+	case "shared":
+		f.T, f.Si = "shared", formula.SI
+	case "array":
+		f.T = "array"
+	}
+	c.F = f
+	if err := sw.encoder.Encode(c); err != nil {
+		return err
+	}
+	sw.calcChainCells = append(sw.calcChainCells, axis)
+	return nil
+}
+
+// makeStreamCell builds the xlsxC representation of a single streamed cell
+// value and its style, shared by StreamWriter.SetRow and StreamFile.Write.
+// If intern is non-nil, string and []byte values are passed through it
+// instead of being written inline, so callers can route them through a
+// shared strings table.
+func makeStreamCell(axis string, style int, val interface{}, intern func(string) (string, error)) (xlsxC, error) {
+	var err error
+	c := xlsxC{R: axis, S: style}
+	switch val := val.(type) {
+	case int:
+		c.T, c.V = setCellInt(val)
+	case int8:
+		c.T, c.V = setCellInt(int(val))
+	case int16:
+		c.T, c.V = setCellInt(int(val))
+	case int32:
+		c.T, c.V = setCellInt(int(val))
+	case int64:
+		c.T, c.V = setCellInt(int(val))
+	case uint:
+		c.T, c.V = setCellInt(int(val))
+	case uint8:
+		c.T, c.V = setCellInt(int(val))
+	case uint16:
+		c.T, c.V = setCellInt(int(val))
+	case uint32:
+		c.T, c.V = setCellInt(int(val))
+	case uint64:
+		c.T, c.V = setCellInt(int(val))
+	case float32:
+		c.T, c.V = setCellFloat(float64(val), -1, 32)
+	case float64:
+		c.T, c.V = setCellFloat(val, -1, 64)
+	case string:
+		if intern != nil {
+			c.T = "s"
+			c.V, err = intern(val)
+		} else {
+			c.T, c.V, c.XMLSpace = setCellStr(val)
+		}
+	case []byte:
+		if intern != nil {
+			c.T = "s"
+			c.V, err = intern(string(val))
+		} else {
+			c.T, c.V, c.XMLSpace = setCellStr(string(val))
+		}
+	case time.Duration:
+		c.T, c.V = setCellDuration(val)
+	case time.Time:
+		c.T, c.V, _, err = setCellTime(val)
+	case bool:
+		c.T, c.V = setCellBool(val)
+	case nil:
+		c.T, c.V, c.XMLSpace = setCellStr("")
+	default:
+		c.T, c.V, c.XMLSpace = setCellStr(fmt.Sprint(val))
+	}
+	return c, err
+}
+
+// sstPlaceholder is the format used to mark a cell value as a reference
+// into a streamWriterSST's interned strings while rows are still being
+// streamed out. The local index it carries is rewritten to the workbook's
+// merged shared strings table index by mergeSharedStrings when Flush runs.
+const sstPlaceholder = "##SST%d##"
+
+// defaultStreamWriterSSTSpillThreshold is the number of distinct strings a
+// streamWriterSST keeps in its in-memory dedup index before spilling to a
+// temporary file, used when StreamWriterOptions.SharedStringsSpillThreshold
+// is not set.
+const defaultStreamWriterSSTSpillThreshold = 1 << 20
+
+// streamWriterSST is the write-side counterpart to streamSharedStrings: it
+// interns string cell values into a local table while SetRow streams rows
+// out, handing back an sstPlaceholder to write in place of the value. Once
+// the number of distinct strings crosses its threshold, the dedup index
+// itself is spilled to a temporary file so deduplicating millions of
+// unique strings doesn't grow the index without bound; strings seen after
+// that point are appended to the file without a lookup, trading perfect
+// deduplication for bounded memory, the same trade-off StreamReader makes
+// on the read side.
+type streamWriterSST struct {
+	index     map[string]int
+	inMemory  []string
+	tmpFile   *os.File
+	count     int
+	refs      int
+	threshold int
+	tempDir   string
+}
+
+// newStreamWriterSST creates a streamWriterSST that spills to tempDir (or
+// os.TempDir() if empty) once more than threshold distinct strings have
+// been interned.
+func newStreamWriterSST(threshold int, tempDir string) *streamWriterSST {
+	if threshold <= 0 {
+		threshold = defaultStreamWriterSSTSpillThreshold
+	}
+	return &streamWriterSST{index: make(map[string]int), threshold: threshold, tempDir: tempDir}
+}
+
+// intern returns the sstPlaceholder to write for value, assigning it a new
+// index the first time value is seen. refs counts every call, including
+// repeats, since that is what xlsxSST.Count tracks, as distinct from the
+// unique string count.
+func (sst *streamWriterSST) intern(value string) (string, error) {
+	sst.refs++
+	if sst.tmpFile != nil {
+		idx := sst.count
+		sst.count++
+		if err := sst.appendToDisk(value); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(sstPlaceholder, idx), nil
+	}
+	if idx, ok := sst.index[value]; ok {
+		return fmt.Sprintf(sstPlaceholder, idx), nil
+	}
+	idx := sst.count
+	sst.count++
+	sst.index[value] = idx
+	sst.inMemory = append(sst.inMemory, value)
+	if len(sst.index) <= sst.threshold {
+		return fmt.Sprintf(sstPlaceholder, idx), nil
+	}
+	if err := sst.spill(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(sstPlaceholder, idx), nil
+}
+
+// spill moves the in-memory dedup index to a temporary file once the
+// configured threshold is crossed, after which new strings are appended to
+// the file directly instead of growing the index.
+func (sst *streamWriterSST) spill() error {
+	dir := sst.tempDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	tmpFile, err := ioutil.TempFile(dir, "excelize-sst-")
+	if err != nil {
+		return err
+	}
+	sst.tmpFile = tmpFile
+	for _, s := range sst.inMemory {
+		if err := sst.appendToDisk(s); err != nil {
+			return err
+		}
+	}
+	sst.inMemory = nil
+	sst.index = nil
+	return nil
+}
+
+// appendToDisk writes a single length-prefixed string to the end of the
+// spill file.
+func (sst *streamWriterSST) appendToDisk(s string) error {
+	var lenBuf [8]byte
+	binary.BigEndian.PutUint64(lenBuf[:], uint64(len(s)))
+	if _, err := sst.tmpFile.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := sst.tmpFile.WriteString(s)
+	return err
+}
+
+// forEach calls fn once for every interned string, in the order indices
+// were assigned, reading them back from the spill file if the table was
+// spilled to disk.
+func (sst *streamWriterSST) forEach(fn func(s string) error) error {
+	if sst.tmpFile == nil {
+		for _, s := range sst.inMemory {
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, err := sst.tmpFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	for {
+		var lenBuf [8]byte
+		if _, err := io.ReadFull(sst.tmpFile, lenBuf[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		buf := make([]byte, binary.BigEndian.Uint64(lenBuf[:]))
+		if _, err := io.ReadFull(sst.tmpFile, buf); err != nil {
+			return err
+		}
+		if err := fn(string(buf)); err != nil {
+			return err
+		}
+	}
+}
+
+// close removes the temporary spill file, if any was created.
+func (sst *streamWriterSST) close() error {
+	if sst.tmpFile == nil {
+		return nil
+	}
+	name := sst.tmpFile.Name()
+	err := sst.tmpFile.Close()
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	sst.tmpFile = nil
+	return err
+}
+
+// mergeSharedStrings merges the strings interned while streaming rows into
+// the workbook's shared strings table, adding the part to
+// [Content_Types].xml and the workbook relationships if it wasn't already
+// present, then rewrites the sstPlaceholder references in sheetDataByte to
+// the resulting table indices.
+func (sw *StreamWriter) mergeSharedStrings(sheetDataByte []byte) ([]byte, error) {
+	if !sw.options.UseSharedStrings || sw.sst.count == 0 {
+		return sheetDataByte, nil
+	}
+	sst := sw.File.sharedStringsReader()
+	existing := make(map[string]int, len(sst.SI))
+	for i, si := range sst.SI {
+		existing[si.T] = i
+	}
+	resolve := make(map[int]int, sw.sst.count)
+	i := 0
+	err := sw.sst.forEach(func(s string) error {
+		idx, ok := existing[s]
+		if !ok {
+			idx = len(sst.SI)
+			sst.SI = append(sst.SI, xlsxSI{T: s})
+			existing[s] = idx
+		}
+		resolve[i] = idx
+		i++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sheetDataByte, err = rewriteSharedStringIndices(sheetDataByte, resolve)
+	if err != nil {
+		return nil, err
+	}
+	// Count is the total number of shared-string references in the
+	// workbook, including repeats, so add the ones streamed through this
+	// StreamWriter to whatever the file already had rather than
+	// overwriting it with the unique count.
+	sst.Count += sw.sst.refs
+	sst.UniqueCount = len(sst.SI)
+	registerSharedStringsPart(sw.File)
+	if err := sw.sst.close(); err != nil {
+		return nil, err
+	}
+	return sheetDataByte, nil
+}
+
+// sstPlaceholderPattern matches a whole sstPlaceholder token, such as
+// "##SST3##", with nothing else in the matched text.
+var sstPlaceholderPattern = regexp.MustCompile(`^##SST(\d+)##$`)
+
+// rewriteSharedStringIndices replaces each sstPlaceholder found in the
+// character data of a t="s" cell's <v> element with the real shared
+// strings table index from resolve. Unlike a global byte-level
+// find-and-replace, this walks sheetDataByte with an XML decoder and only
+// ever rewrites text the decoder reports as character data inside a
+// shared-string cell, so a literal cell value that happens to look like a
+// placeholder is left untouched.
+func rewriteSharedStringIndices(sheetDataByte []byte, resolve map[int]int) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(sheetDataByte))
+	var out bytes.Buffer
+	var written, offset int64
+	inSharedString := false
+	for {
+		start := offset
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		offset = decoder.InputOffset()
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "c" {
+				inSharedString = false
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "t" && attr.Value == "s" {
+						inSharedString = true
+					}
+				}
+			}
+		case xml.CharData:
+			if !inSharedString {
+				continue
+			}
+			m := sstPlaceholderPattern.FindSubmatch(t)
+			if m == nil {
+				continue
+			}
+			idx, err := strconv.Atoi(string(m[1]))
+			if err != nil {
+				continue
+			}
+			real, ok := resolve[idx]
+			if !ok {
+				continue
+			}
+			out.Write(sheetDataByte[written:start])
+			out.WriteString(strconv.Itoa(real))
+			written = offset
+		}
+	}
+	out.Write(sheetDataByte[written:])
+	return out.Bytes(), nil
+}
+
+
+// registerSharedStringsPart ensures xl/sharedStrings.xml is declared in
+// [Content_Types].xml and wired into the workbook relationships, which is
+// only necessary the first time a workbook gains a shared strings table.
+func registerSharedStringsPart(f *File) {
+	content := f.contentTypesReader()
+	declared := false
+	for _, o := range content.Overrides {
+		if o.PartName == "/xl/sharedStrings.xml" {
+			declared = true
+			break
+		}
+	}
+	if !declared {
+		content.Overrides = append(content.Overrides, xlsxOverride{
+			PartName:    "/xl/sharedStrings.xml",
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sharedStrings+xml",
+		})
+	}
+	rels := f.relsReader("xl/_rels/workbook.xml.rels")
+	for _, r := range rels.Relationships {
+		if r.Target == "sharedStrings.xml" {
+			return
+		}
+	}
+	rels.Relationships = append(rels.Relationships, xlsxRelationship{
+		ID:     fmt.Sprintf("rId%d", len(rels.Relationships)+1),
+		Type:   SourceRelationshipSharedStrings,
+		Target: "sharedStrings.xml",
+	})
+}
+
 // setCellStr provides a function to set string type value of a cell as
 // streaming. Total number of characters that a cell can contain 32767
 // characters.
@@ -0,0 +1,310 @@
+// Copyright 2016 - 2020 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX files. Support reads and writes XLSX file generated by
+// Microsoft Excel™ 2007 and later. Support save file without losing original
+// charts of XLSX. This library needs Go version 1.10 or later.
+
+package excelize
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// StreamFileBuilder assembles a workbook sheet by sheet and streams the
+// resulting zip archive straight to an io.Writer, for example an
+// http.ResponseWriter, as each sheet is finished. Unlike NewStreamWriter,
+// which still folds the finished worksheet back into File.XLSX on Flush,
+// the row data written through a StreamFileBuilder is never held in memory
+// or written back into File.XLSX. The File it was created from does record
+// the registered sheets (and loses any sheet that was not registered), and
+// its shared strings table holds every string written. Register every
+// sheet with AddSheet, then call Build once to obtain a StreamFile.
+type StreamFileBuilder struct {
+	file     *File
+	sheets   []*streamFileBuilderSheet
+	built    bool
+	sstIndex map[string]int
+}
+
+// streamFileBuilderSheet holds the header row and per-column styles
+// registered for a sheet via StreamFileBuilder.AddSheet.
+type streamFileBuilderSheet struct {
+	name    string
+	headers []interface{}
+	styles  []int
+}
+
+// NewStreamFileBuilder creates a StreamFileBuilder backed by f. Sheets added
+// through it are created in f immediately via f.NewSheet, so content types,
+// the workbook part and its relationships stay consistent while the actual
+// row data is streamed out later by Build.
+func NewStreamFileBuilder(f *File) *StreamFileBuilder {
+	return &StreamFileBuilder{file: f}
+}
+
+// AddSheet registers a new sheet with the given name, header row and
+// optional per-column styles, which are applied to the header cells. If
+// styles is non-nil, it must be the same length as headers. Sheets are
+// streamed to the destination io.Writer in the order they are added.
+// AddSheet must be called before Build.
+func (b *StreamFileBuilder) AddSheet(name string, headers []interface{}, styles []int) error {
+	if b.built {
+		return errors.New("AddSheet must be called before Build")
+	}
+	if styles != nil && len(styles) != len(headers) {
+		return errors.New("incorrect number of styles for this row")
+	}
+	for _, sheet := range b.sheets {
+		if sheet.name == name {
+			return fmt.Errorf("sheet %s has already been added", name)
+		}
+	}
+	if b.file.GetSheetIndex(name) == 0 {
+		b.file.NewSheet(name)
+	}
+	b.sheets = append(b.sheets, &streamFileBuilderSheet{name: name, headers: headers, styles: styles})
+	return nil
+}
+
+// intern interns value into the workbook's shared strings table
+// immediately and returns the index to write as the cell value. Unlike
+// StreamWriter, which buffers a sheet's data in memory and can rewrite
+// placeholder indices once every string is known, StreamFile writes each
+// row straight to the destination io.Writer as it's produced, so the
+// final index has to be assigned at write time instead.
+func (b *StreamFileBuilder) intern(value string) (string, error) {
+	sst := b.file.sharedStringsReader()
+	if b.sstIndex == nil {
+		b.sstIndex = make(map[string]int, len(sst.SI))
+		for i, si := range sst.SI {
+			b.sstIndex[si.T] = i
+		}
+	}
+	sst.Count++
+	idx, ok := b.sstIndex[value]
+	if !ok {
+		idx = len(sst.SI)
+		sst.SI = append(sst.SI, xlsxSI{T: value})
+		sst.UniqueCount = len(sst.SI)
+		b.sstIndex[value] = idx
+	}
+	return strconv.Itoa(idx), nil
+}
+
+// Build finalizes the registered sheets, writes the parts of the workbook
+// that do not depend on row data (the OPC root relationships,
+// [Content_Types].xml, the workbook part, its relationships, styles,
+// document properties and theme) to w, and returns a StreamFile positioned
+// at the first registered sheet and ready to receive rows via Write. Build
+// may only be called once; no further sheets can be added afterwards.
+func (b *StreamFileBuilder) Build(w io.Writer) (*StreamFile, error) {
+	if b.built {
+		return nil, errors.New("Build may only be called once")
+	}
+	if len(b.sheets) == 0 {
+		return nil, errors.New("at least one sheet must be added before calling Build")
+	}
+	b.built = true
+	b.removeUnregisteredSheets()
+	sf := &StreamFile{builder: b, zw: zip.NewWriter(w), sheetIndex: -1}
+	if err := sf.writeSkeleton(); err != nil {
+		return nil, err
+	}
+	if err := sf.NextSheet(); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+// removeUnregisteredSheets drops any sheet that exists in the underlying
+// File but was never registered via AddSheet, for example the default
+// "Sheet1" left over from excelize.NewFile(). Build only ever writes a
+// xl/worksheets/sheetN.xml entry for registered sheets, so a sheet left
+// behind in xl/workbook.xml and [Content_Types].xml without a matching
+// worksheet part would otherwise produce a corrupt package.
+func (b *StreamFileBuilder) removeUnregisteredSheets() {
+	registered := make(map[string]bool, len(b.sheets))
+	for _, sheet := range b.sheets {
+		registered[sheet.name] = true
+	}
+	for _, name := range b.file.GetSheetList() {
+		if !registered[name] {
+			b.file.DeleteSheet(name)
+		}
+	}
+}
+
+// StreamFile streams the workbook assembled by a StreamFileBuilder to an
+// io.Writer one sheet at a time. Call Write once per data row, NextSheet to
+// finish the current sheet and move on to the next one registered with
+// AddSheet, and Close once every sheet has been written to finish the zip
+// archive.
+type StreamFile struct {
+	builder    *StreamFileBuilder
+	zw         *zip.Writer
+	sheetIndex int
+	fw         io.Writer
+	rowCount   int
+	closed     bool
+}
+
+// streamFileBuilderRawParts are workbook parts that StreamFile doesn't
+// rebuild from a struct, so they're copied through unchanged from the
+// File's raw XLSX bytes if present.
+var streamFileBuilderRawParts = []string{
+	"docProps/core.xml",
+	"docProps/app.xml",
+	"xl/theme/theme1.xml",
+}
+
+// writeSkeleton emits the workbook parts that do not depend on the row data
+// being streamed.
+func (sf *StreamFile) writeSkeleton() error {
+	f := sf.builder.file
+	// Registered before [Content_Types].xml and the workbook rels are
+	// serialized below, so the shared strings part declared here actually
+	// makes it into those parts instead of only being added to the
+	// in-memory structs after they've already been written to the zip.
+	registerSharedStringsPart(f)
+	parts := []struct {
+		name    string
+		content interface{}
+	}{
+		{"_rels/.rels", f.relsReader("_rels/.rels")},
+		{"[Content_Types].xml", f.contentTypesReader()},
+		{"xl/workbook.xml", f.workBookReader()},
+		{"xl/_rels/workbook.xml.rels", f.relsReader("xl/_rels/workbook.xml.rels")},
+		{"xl/styles.xml", f.stylesReader()},
+	}
+	for _, part := range parts {
+		if err := writeXMLToZipWriter(sf.zw, part.name, part.content); err != nil {
+			return err
+		}
+	}
+	for _, name := range streamFileBuilderRawParts {
+		if err := sf.copyRawPart(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyRawPart copies a workbook part straight from the File's raw XLSX
+// bytes, if it exists, skipping it otherwise.
+func (sf *StreamFile) copyRawPart(name string) error {
+	data, ok := sf.builder.file.XLSX[name]
+	if !ok {
+		return nil
+	}
+	fw, err := sf.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+// NextSheet closes the sheet currently being written and opens the next
+// sheet registered with AddSheet, writing its header row immediately.
+// NextSheet is called once automatically by Build to open the first sheet;
+// call it again after the last data row of a sheet to move on to the next
+// one.
+func (sf *StreamFile) NextSheet() error {
+	if sf.closed {
+		return errors.New("NextSheet called on a closed StreamFile")
+	}
+	if sf.fw != nil {
+		if _, err := io.WriteString(sf.fw, `</sheetData></worksheet>`); err != nil {
+			return err
+		}
+	}
+	sf.sheetIndex++
+	if sf.sheetIndex >= len(sf.builder.sheets) {
+		sf.fw = nil
+		return fmt.Errorf("no more sheets to write, %d sheet(s) were registered", len(sf.builder.sheets))
+	}
+	sheet := sf.builder.sheets[sf.sheetIndex]
+	sheetID := sf.builder.file.GetSheetIndex(sheet.name)
+	fw, err := sf.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", sheetID))
+	if err != nil {
+		return err
+	}
+	sf.fw = fw
+	sf.rowCount = 0
+	if _, err := io.WriteString(sf.fw, XMLHeader+`<worksheet`+templateNamespaceIDMap+`><sheetData>`); err != nil {
+		return err
+	}
+	return sf.writeRow(sheet.headers, sheet.styles)
+}
+
+// Write appends a data row to the sheet currently being written. The number
+// of values must match the number of headers given to AddSheet for this
+// sheet.
+func (sf *StreamFile) Write(row []interface{}) error {
+	if sf.fw == nil {
+		return errors.New("Write called before NextSheet or after the last sheet was closed")
+	}
+	sheet := sf.builder.sheets[sf.sheetIndex]
+	if len(row) != len(sheet.headers) {
+		return fmt.Errorf("incorrect number of cells in row, expected %d, got %d", len(sheet.headers), len(row))
+	}
+	return sf.writeRow(row, nil)
+}
+
+// writeRow encodes a single row of values, using styles if non-nil, and
+// writes it directly to the zip entry for the sheet currently being
+// written.
+func (sf *StreamFile) writeRow(values []interface{}, styles []int) error {
+	sf.rowCount++
+	if _, err := io.WriteString(sf.fw, fmt.Sprintf(`<row r="%d">`, sf.rowCount)); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(sf.fw)
+	for i, val := range values {
+		axis, err := CoordinatesToCellName(i+1, sf.rowCount)
+		if err != nil {
+			return err
+		}
+		style := 0
+		if styles != nil {
+			style = styles[i]
+		}
+		c, err := makeStreamCell(axis, style, val, sf.builder.intern)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(c); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(sf.fw, `</row>`)
+	return err
+}
+
+// Close finishes the sheet currently being written, writes out
+// xl/sharedStrings.xml with the strings interned from every sheet, and
+// closes the zip archive written to the io.Writer given to Build.
+func (sf *StreamFile) Close() error {
+	if sf.closed {
+		return nil
+	}
+	sf.closed = true
+	if sf.fw != nil {
+		if _, err := io.WriteString(sf.fw, `</sheetData></worksheet>`); err != nil {
+			return err
+		}
+		sf.fw = nil
+	}
+	if err := writeXMLToZipWriter(sf.zw, "xl/sharedStrings.xml", sf.builder.file.sharedStringsReader()); err != nil {
+		return err
+	}
+	return sf.zw.Close()
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 - 2020 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX files. Support reads and writes XLSX file generated by
+// Microsoft Excel™ 2007 and later. Support save file without losing original
+// charts of XLSX. This library needs Go version 1.10 or later.
+
+package excelize
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStreamFileBuilderRoundTrip builds a one-sheet workbook with repeated
+// string values straight to a buffer, reopens it, and checks the rows and
+// the shared strings table both survived.
+func TestStreamFileBuilderRoundTrip(t *testing.T) {
+	f := NewFile()
+	b := NewStreamFileBuilder(f)
+	require.NoError(t, b.AddSheet("Report", []interface{}{"Name", "Status"}, nil))
+
+	var buf bytes.Buffer
+	sf, err := b.Build(&buf)
+	require.NoError(t, err)
+	require.NoError(t, sf.Write([]interface{}{"Alice", "active"}))
+	require.NoError(t, sf.Write([]interface{}{"Bob", "active"}))
+	require.NoError(t, sf.Close())
+
+	file := filepath.Join(t.TempDir(), "TestStreamFileBuilderRoundTrip.xlsx")
+	require.NoError(t, ioutil.WriteFile(file, buf.Bytes(), 0644))
+
+	reopened, err := OpenFile(file)
+	require.NoError(t, err)
+	v, err := reopened.GetCellValue("Report", "A1")
+	require.NoError(t, err)
+	assert.Equal(t, "Name", v)
+	v, err = reopened.GetCellValue("Report", "B2")
+	require.NoError(t, err)
+	assert.Equal(t, "active", v)
+	v, err = reopened.GetCellValue("Report", "B3")
+	require.NoError(t, err)
+	assert.Equal(t, "active", v)
+
+	// "active" is repeated across rows 2 and 3, so it should only appear
+	// once in the shared strings table.
+	sst := reopened.sharedStringsReader()
+	seen := 0
+	for _, si := range sst.SI {
+		if si.T == "active" {
+			seen++
+		}
+	}
+	assert.Equal(t, 1, seen)
+}
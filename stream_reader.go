@@ -0,0 +1,368 @@
+// Copyright 2016 - 2020 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX files. Support reads and writes XLSX file generated by
+// Microsoft Excel™ 2007 and later. Support save file without losing original
+// charts of XLSX. This library needs Go version 1.10 or later.
+
+package excelize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// streamSharedStringsSpillThreshold is the number of entries above which
+// the shared strings table read by a StreamReader is spilled to a
+// temporary file instead of being kept fully in memory.
+const streamSharedStringsSpillThreshold = 1 << 20
+
+// Cell represents a single worksheet cell yielded by StreamReader, with its
+// value resolved against the shared strings table and its style and number
+// format looked up from the workbook's styles.
+type Cell struct {
+	Axis    string
+	Value   string
+	StyleID int
+	NumFmt  string
+}
+
+// StreamReader reads the rows of a worksheet one at a time using
+// encoding/xml's token-based Decoder, instead of decoding the whole
+// worksheet into an xlsxWorksheet the way workSheetReader does. Use it to
+// read very large worksheets, such as a multi-GB upload, without holding
+// the full sheet, or the result of GetRows, in memory.
+type StreamReader struct {
+	file    *File
+	decoder *xml.Decoder
+	sst     *streamSharedStrings
+	row     []Cell
+	err     error
+	done    bool
+}
+
+// NewStreamReader returns a StreamReader over the given worksheet name.
+// Call Next to advance to each row in turn, Row to read the current row's
+// cells, and Err once Next returns false to check whether iteration
+// stopped because of an error rather than reaching the end of the sheet.
+//
+//    streamReader, err := f.NewStreamReader("Sheet1")
+//    if err != nil {
+//        panic(err)
+//    }
+//    for streamReader.Next() {
+//        for _, cell := range streamReader.Row() {
+//            fmt.Println(cell.Axis, cell.Value)
+//        }
+//    }
+//    if err := streamReader.Err(); err != nil {
+//        panic(err)
+//    }
+//
+func (f *File) NewStreamReader(sheet string) (*StreamReader, error) {
+	sheetID := f.GetSheetIndex(sheet)
+	if sheetID == 0 {
+		return nil, fmt.Errorf("sheet %s is not exist", sheet)
+	}
+	// f.Sheet holds the decoded, possibly edited worksheet (for example
+	// after SetCellValue), which can be ahead of the raw bytes in f.XLSX
+	// from the last load or save. Flush it back before snapshotting the
+	// raw bytes for the decoder, the same sync StreamFileBuilder performs
+	// via workBookReader/stylesReader/relsReader.
+	f.workSheetWriter()
+	name := fmt.Sprintf("xl/worksheets/sheet%d.xml", sheetID)
+	data, ok := f.XLSX[name]
+	if !ok {
+		return nil, fmt.Errorf("sheet %s is not exist", sheet)
+	}
+	sst, err := f.streamSharedStringsReader()
+	if err != nil {
+		return nil, err
+	}
+	return &StreamReader{
+		file:    f,
+		decoder: f.xmlNewDecoder(bytes.NewReader(data)),
+		sst:     sst,
+	}, nil
+}
+
+// Next advances the StreamReader to the next row of the worksheet,
+// returning false once the end of the sheet is reached or an error occurs.
+func (sr *StreamReader) Next() bool {
+	if sr.done {
+		return false
+	}
+	for {
+		tok, err := sr.decoder.Token()
+		if err == io.EOF {
+			sr.done = true
+			return false
+		}
+		if err != nil {
+			sr.err = err
+			sr.done = true
+			return false
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "row" {
+			continue
+		}
+		row, err := sr.decodeRow(se)
+		if err != nil {
+			sr.err = err
+			sr.done = true
+			return false
+		}
+		sr.row = row
+		return true
+	}
+}
+
+// decodeRow reads the <c> children of a <row> start element already
+// consumed from the decoder, returning once the matching end element is
+// reached.
+func (sr *StreamReader) decodeRow(row xml.StartElement) ([]Cell, error) {
+	var cells []Cell
+	for {
+		tok, err := sr.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				if err := sr.decoder.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			var c xlsxC
+			if err := sr.decoder.DecodeElement(&c, &t); err != nil {
+				return nil, err
+			}
+			cells = append(cells, sr.resolveCell(c))
+		case xml.EndElement:
+			if t.Name.Local == row.Name.Local {
+				return cells, nil
+			}
+		}
+	}
+}
+
+// resolveCell turns a raw xlsxC into a Cell, looking up shared string
+// values and the cell's number format.
+func (sr *StreamReader) resolveCell(c xlsxC) Cell {
+	cell := Cell{Axis: c.R, Value: c.V, StyleID: c.S}
+	if c.T == "s" {
+		if idx, err := strconv.Atoi(c.V); err == nil {
+			cell.Value = sr.sst.get(idx)
+		}
+	}
+	cell.NumFmt = sr.file.numFmtByStyleID(c.S)
+	return cell
+}
+
+// Row returns the cells of the row the StreamReader is currently
+// positioned at. It is only valid after a call to Next that returned true.
+func (sr *StreamReader) Row() []Cell {
+	return sr.row
+}
+
+// Err returns the first error, if any, encountered while advancing the
+// StreamReader.
+func (sr *StreamReader) Err() error {
+	return sr.err
+}
+
+// Close releases resources held by the StreamReader, including the
+// temporary file used to spill a large shared strings table to disk.
+func (sr *StreamReader) Close() error {
+	if sr.sst == nil || sr.sst.tmpFile == nil {
+		return nil
+	}
+	name := sr.sst.tmpFile.Name()
+	if err := sr.sst.tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// streamSharedStrings is an indexable view over xl/sharedStrings.xml built
+// by token-by-token parsing, so a very large table doesn't have to be
+// decoded into an xlsxSST struct in one shot. Once the number of entries
+// crosses streamSharedStringsSpillThreshold, strings are appended to a
+// temporary file instead of being kept in memory.
+type streamSharedStrings struct {
+	inMemory []string
+	tmpFile  *os.File
+	offsets  []int64
+}
+
+// streamSharedStringsReader parses xl/sharedStrings.xml, if present, into a
+// streamSharedStrings.
+func (f *File) streamSharedStringsReader() (*streamSharedStrings, error) {
+	// Strings interned through the normal cell-mutation API (for example
+	// SetCellValue) live in the decoded f.SharedStrings cache until
+	// something serializes them back to f.XLSX; flush that cache first so
+	// a sheet edited in memory before NewStreamReader is called doesn't
+	// read a stale table.
+	f.sharedStringsWriter()
+	sst := &streamSharedStrings{}
+	data, ok := f.XLSX["xl/sharedStrings.xml"]
+	if !ok {
+		return sst, nil
+	}
+	decoder := f.xmlNewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "si" {
+			continue
+		}
+		var si xlsxSI
+		if err := decoder.DecodeElement(&si, &se); err != nil {
+			return nil, err
+		}
+		if err := sst.add(si.T); err != nil {
+			return nil, err
+		}
+	}
+	return sst, nil
+}
+
+// add appends a shared string, spilling the table to a temporary file once
+// streamSharedStringsSpillThreshold entries have accumulated in memory.
+func (sst *streamSharedStrings) add(s string) error {
+	if sst.tmpFile == nil && len(sst.inMemory) >= streamSharedStringsSpillThreshold {
+		tmpFile, err := ioutil.TempFile(os.TempDir(), "excelize-sst-")
+		if err != nil {
+			return err
+		}
+		sst.tmpFile = tmpFile
+		inMemory := sst.inMemory
+		sst.inMemory = nil
+		for _, v := range inMemory {
+			if err := sst.appendToDisk(v); err != nil {
+				return err
+			}
+		}
+	}
+	if sst.tmpFile != nil {
+		return sst.appendToDisk(s)
+	}
+	sst.inMemory = append(sst.inMemory, s)
+	return nil
+}
+
+// appendToDisk writes a single length-prefixed string to the end of the
+// spill file and records its offset.
+func (sst *streamSharedStrings) appendToDisk(s string) error {
+	offset, err := sst.tmpFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	sst.offsets = append(sst.offsets, offset)
+	var lenBuf [8]byte
+	binary.BigEndian.PutUint64(lenBuf[:], uint64(len(s)))
+	if _, err := sst.tmpFile.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err = sst.tmpFile.WriteString(s)
+	return err
+}
+
+// get returns the shared string at idx, or the empty string if idx is out
+// of range or reading it back from the spill file fails.
+func (sst *streamSharedStrings) get(idx int) string {
+	if sst.tmpFile == nil {
+		if idx < 0 || idx >= len(sst.inMemory) {
+			return ""
+		}
+		return sst.inMemory[idx]
+	}
+	if idx < 0 || idx >= len(sst.offsets) {
+		return ""
+	}
+	if _, err := sst.tmpFile.Seek(sst.offsets[idx], io.SeekStart); err != nil {
+		return ""
+	}
+	var lenBuf [8]byte
+	if _, err := io.ReadFull(sst.tmpFile, lenBuf[:]); err != nil {
+		return ""
+	}
+	buf := make([]byte, binary.BigEndian.Uint64(lenBuf[:]))
+	if _, err := io.ReadFull(sst.tmpFile, buf); err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+// builtInNumFmt maps the built-in number format IDs defined by the OOXML
+// spec (ECMA-376 18.8.30) to their format codes. Custom formats declared in
+// xl/styles.xml's numFmts carry their own ID starting at 164 and are looked
+// up there instead; numFmtByStyleID only falls back to this table for the
+// built-in IDs every workbook is allowed to use without declaring them.
+var builtInNumFmt = map[int]string{
+	0:  "general",
+	1:  "0",
+	2:  "0.00",
+	3:  "#,##0",
+	4:  "#,##0.00",
+	9:  "0%",
+	10: "0.00%",
+	11: "0.00E+00",
+	12: "# ?/?",
+	13: "# ??/??",
+	14: "m/d/yy",
+	15: "d-mmm-yy",
+	16: "d-mmm",
+	17: "mmm-yy",
+	18: "h:mm AM/PM",
+	19: "h:mm:ss AM/PM",
+	20: "h:mm",
+	21: "h:mm:ss",
+	22: "m/d/yy h:mm",
+	37: "#,##0 ;(#,##0)",
+	38: "#,##0 ;[Red](#,##0)",
+	39: "#,##0.00;(#,##0.00)",
+	40: "#,##0.00;[Red](#,##0.00)",
+	45: "mm:ss",
+	46: "[h]:mm:ss",
+	47: "mmss.0",
+	48: "##0.0E+0",
+	49: "@",
+}
+
+// numFmtByStyleID looks up the number format code applied to styleID,
+// returning the empty string if styleID is not in the workbook's cellXfs or
+// its number format ID is neither declared nor built in. A style using the
+// default numFmtId 0 yields "general".
+func (f *File) numFmtByStyleID(styleID int) string {
+	styles := f.stylesReader()
+	if styles == nil || styles.CellXfs == nil || styleID < 0 || styleID >= len(styles.CellXfs.Xf) {
+		return ""
+	}
+	numFmtID := styles.CellXfs.Xf[styleID].NumFmtID
+	if styles.NumFmts != nil {
+		for _, nf := range styles.NumFmts.NumFmt {
+			if nf.NumFmtID == numFmtID {
+				return nf.FormatCode
+			}
+		}
+	}
+	return builtInNumFmt[numFmtID]
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 - 2020 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX files. Support reads and writes XLSX file generated by
+// Microsoft Excel™ 2007 and later. Support save file without losing original
+// charts of XLSX. This library needs Go version 1.10 or later.
+
+package excelize
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamReader(t *testing.T) {
+	f := NewFile()
+	streamWriter, err := f.NewStreamWriter("Sheet1")
+	require.NoError(t, err)
+	rows := [][]interface{}{
+		{"Name", "Age", "Score"},
+		{"Alice", 30, 92.5},
+		{"Bob", 25, 88.125},
+	}
+	for i, row := range rows {
+		cell, err := CoordinatesToCellName(1, i+1)
+		require.NoError(t, err)
+		require.NoError(t, streamWriter.SetRow(cell, row, nil))
+	}
+	require.NoError(t, streamWriter.Flush())
+
+	streamReader, err := f.NewStreamReader("Sheet1")
+	require.NoError(t, err)
+	var got [][]string
+	for streamReader.Next() {
+		var row []string
+		for _, cell := range streamReader.Row() {
+			row = append(row, cell.Value)
+		}
+		got = append(got, row)
+	}
+	require.NoError(t, streamReader.Err())
+	assert.Equal(t, [][]string{
+		{"Name", "Age", "Score"},
+		{"Alice", "30", "92.5"},
+		{"Bob", "25", "88.125"},
+	}, got)
+}
+
+// TestStreamReaderSeesInMemoryEdits ensures NewStreamReader reflects edits
+// made through the normal cell-mutation API before it was called, not the
+// stale raw bytes left over from the last load or save.
+func TestStreamReaderSeesInMemoryEdits(t *testing.T) {
+	f := NewFile()
+	require.NoError(t, f.SetCellValue("Sheet1", "A1", "before"))
+
+	file := filepath.Join(t.TempDir(), "TestStreamReaderSeesInMemoryEdits.xlsx")
+	require.NoError(t, f.SaveAs(file))
+
+	reopened, err := OpenFile(file)
+	require.NoError(t, err)
+	require.NoError(t, reopened.SetCellValue("Sheet1", "A1", "after"))
+
+	streamReader, err := reopened.NewStreamReader("Sheet1")
+	require.NoError(t, err)
+	require.True(t, streamReader.Next())
+	row := streamReader.Row()
+	require.Len(t, row, 1)
+	assert.Equal(t, "after", row[0].Value)
+}
+
+func TestNumFmtByStyleID(t *testing.T) {
+	f := NewFile()
+	styleID, err := f.NewStyle(`{"number_format": 14}`)
+	require.NoError(t, err)
+	require.NoError(t, f.SetCellStyle("Sheet1", "A1", "A1", styleID))
+	require.NoError(t, f.SetCellValue("Sheet1", "A1", 44197))
+
+	streamReader, err := f.NewStreamReader("Sheet1")
+	require.NoError(t, err)
+	require.True(t, streamReader.Next())
+	assert.Equal(t, "m/d/yy", streamReader.Row()[0].NumFmt)
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 - 2020 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX files. Support reads and writes XLSX file generated by
+// Microsoft Excel™ 2007 and later. Support save file without losing original
+// charts of XLSX. This library needs Go version 1.10 or later.
+
+package excelize
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStreamWriterRoundTrip writes rows with plain values, merged cells and
+// a formula via StreamWriter, reopens the saved file and checks that a
+// plain value and the formula's cached result survived.
+func TestStreamWriterRoundTrip(t *testing.T) {
+	f := NewFile()
+	streamWriter, err := f.NewStreamWriter("Sheet1")
+	require.NoError(t, err)
+	require.NoError(t, streamWriter.SetRow("A1", []interface{}{"Header A", "Header B"}, nil))
+	require.NoError(t, streamWriter.SetRow("A2", []interface{}{1, Formula{F: "A2*2", Result: 2}}, nil))
+	require.NoError(t, streamWriter.MergeCell("A1", "A1"))
+	require.NoError(t, streamWriter.Flush())
+
+	file := filepath.Join(t.TempDir(), "TestStreamWriterRoundTrip.xlsx")
+	require.NoError(t, f.SaveAs(file))
+
+	reopened, err := OpenFile(file)
+	require.NoError(t, err)
+	v, err := reopened.GetCellValue("Sheet1", "A1")
+	require.NoError(t, err)
+	assert.Equal(t, "Header A", v)
+	v, err = reopened.GetCellValue("Sheet1", "B2")
+	require.NoError(t, err)
+	assert.Equal(t, "2", v)
+}
+
+// TestStreamWriterSharedStringsCount ensures Flush adds the references
+// streamed through a StreamWriter to the workbook's existing shared
+// strings Count instead of clobbering it with the unique string count.
+func TestStreamWriterSharedStringsCount(t *testing.T) {
+	f := NewFile()
+	require.NoError(t, f.SetCellValue("Sheet1", "A1", "existing"))
+
+	streamWriter, err := f.NewStreamWriterWithOptions("Sheet1", StreamWriterOptions{UseSharedStrings: true})
+	require.NoError(t, err)
+	require.NoError(t, streamWriter.SetRow("A2", []interface{}{"repeat", "repeat", "unique"}, nil))
+	require.NoError(t, streamWriter.Flush())
+
+	file := filepath.Join(t.TempDir(), "TestStreamWriterSharedStringsCount.xlsx")
+	require.NoError(t, f.SaveAs(file))
+
+	reopened, err := OpenFile(file)
+	require.NoError(t, err)
+	sst := reopened.sharedStringsReader()
+	// "existing" (from the plain SetCellValue call) plus the three cells
+	// streamed through StreamWriter: 4 references over 3 unique strings.
+	assert.Equal(t, 4, sst.Count)
+	assert.Equal(t, 3, sst.UniqueCount)
+
+	v, err := reopened.GetCellValue("Sheet1", "A2")
+	require.NoError(t, err)
+	assert.Equal(t, "repeat", v)
+	v, err = reopened.GetCellValue("Sheet1", "C2")
+	require.NoError(t, err)
+	assert.Equal(t, "unique", v)
+}